@@ -0,0 +1,669 @@
+// Package jsonlex implements a streaming lexer for JSON text. It reads
+// runes from an io.RuneReader instead of buffering the whole document in
+// memory, so it can tokenize documents larger than memory or fed
+// incrementally from a network socket.
+package jsonlex
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf16"
+)
+
+// Item is a single lexical token produced by the Lexer.
+type Item struct {
+	Type    ItemType  // Type, such as ItemNumber
+	Val     string    // raw source text, such as "6523.423e23" or `"café"`
+	Decoded string    // for ItemString, Val with escapes resolved and quotes stripped
+	Offset  int       // token position, in runes, from the start of the stream
+	Line    int       // 1-based line the token starts on
+	Col     int       // 1-based column the token starts on
+	Err     *LexError // set when Type == ItemError
+}
+
+// LexError describes where and why lexing failed.
+type LexError struct {
+	Msg     string
+	Offset  int
+	Line    int
+	Col     int
+	Snippet string // short excerpt of recently read input, for context
+}
+
+func (e *LexError) Error() string {
+	return fmt.Sprintf("line %d col %d: %s", e.Line, e.Col, e.Msg)
+}
+
+// ItemType identifies the kind of token an Item represents.
+type ItemType int
+
+const (
+	ItemError ItemType = iota // error occurred; Val holds the message
+
+	ItemEOF
+	ItemString
+	ItemNumber
+	ItemObjectBegin
+	ItemObjectEnd
+	ItemBool
+	ItemArrayBegin
+	ItemArrayEnd
+	ItemColon
+	ItemSeparator
+	ItemNull
+
+	// ItemDocumentEnd is a synthetic, zero-width item emitted between two
+	// top-level values in a stream started with NewStream: one for each
+	// document boundary in NDJSON or back-to-back JSON input. It is never
+	// emitted by a Lexer constructed with New.
+	ItemDocumentEnd
+)
+
+const (
+	eof             = 0
+	openObject      = '{'
+	closeObject     = '}'
+	openArray       = '['
+	closeArray      = ']'
+	objectSeparator = ':'
+	stringStart     = '"'
+	stringEnd       = '"'
+	separator       = ','
+	tre             = "true"
+	fls             = "false"
+	nll             = "null"
+)
+
+func (i Item) String() string {
+	switch i.Type {
+	case ItemEOF:
+		return "EOF"
+	case ItemDocumentEnd:
+		return "DOCUMENT_END"
+	case ItemError:
+		return i.Val
+	}
+	if len(i.Val) > 10 {
+		return fmt.Sprintf("%.10q...", i.Val)
+	}
+	return fmt.Sprintf("%q", i.Val)
+}
+
+type stateFn func(lx *Lexer) stateFn
+
+// snippetWindow bounds how many recently read runes are kept for error
+// snippets, so the lexer's memory use stays flat regardless of how much
+// of the stream has been consumed.
+const snippetWindow = 32
+
+// Lexer tokenizes JSON read from an underlying io.RuneReader. It is
+// single-use: construct one with New or NewStream, then drain tokens with
+// NextItem.
+type Lexer struct {
+	name string // used only for error reports
+
+	r          io.RuneReader
+	buf        []rune // runes accumulated for the current item
+	backupRune rune   // rune pushed back by backup, valid when hasBackup
+	hasBackup  bool
+	lastWidth  int // 1 if the previous next() consumed a rune, 0 if it hit eof
+
+	recent []rune // rolling window of recently read runes, for error snippets
+
+	start               int // offset, in runes, of the start of the current item
+	pos                 int // current offset, in runes, from the start of the stream
+	line, col           int // position of pos: 1-based line, 0-based column
+	startLine, startCol int // position of start: 1-based line, 0-based column
+	prevLine, prevCol   int // line/col before the most recent next(), for backup
+
+	stream bool   // true for a Lexer built with NewStream
+	open   []rune // stack of currently open containers: '{' or '[', innermost last
+
+	state stateFn
+	items chan Item
+	once  sync.Once
+}
+
+// New returns a Lexer that reads a single JSON value from r.
+func New(r io.Reader) *Lexer {
+	return newLexer(r, false)
+}
+
+// NewStream returns a Lexer that reads zero or more whitespace- or
+// newline-separated top-level JSON values from r: NDJSON (one value per
+// line) or back-to-back documents of the kind `jq -c` or a Kafka producer
+// might emit. It emits ItemDocumentEnd between documents so downstream
+// parsers know where one value ends and the next begins.
+func NewStream(r io.Reader) *Lexer {
+	return newLexer(r, true)
+}
+
+func newLexer(r io.Reader, stream bool) *Lexer {
+	return &Lexer{
+		r:         bufio.NewReader(r),
+		state:     lexValueStart,
+		items:     make(chan Item, 2),
+		line:      1,
+		startLine: 1,
+		stream:    stream,
+	}
+}
+
+// Run drives the lexer's state machine to completion and closes the item
+// channel when it's done. NextItem starts Run in its own goroutine on its
+// first call, and the items Run sends can be read only through NextItem.
+func (lx *Lexer) Run() {
+	for state := lx.state; state != nil; {
+		state = state(lx)
+	}
+	close(lx.items)
+}
+
+// NextItem returns the next Item produced by the lexer. It starts the
+// lexer's goroutine on the first call. ok is false once the lexer has
+// emitted its final item and the underlying stream is exhausted.
+func (lx *Lexer) NextItem() (Item, bool) {
+	lx.once.Do(func() { go lx.Run() })
+	it, ok := <-lx.items
+	return it, ok
+}
+
+func (lx *Lexer) emit(t ItemType) {
+	lx.items <- Item{Type: t, Val: string(lx.buf), Offset: lx.start, Line: lx.startLine, Col: lx.startCol + 1}
+	lx.startOver()
+}
+
+// emitString emits the string item currently in buf along with its
+// decoded form (escapes resolved, surrounding quotes stripped).
+func (lx *Lexer) emitString(decoded string) {
+	lx.items <- Item{Type: ItemString, Val: string(lx.buf), Decoded: decoded, Offset: lx.start, Line: lx.startLine, Col: lx.startCol + 1}
+	lx.startOver()
+}
+
+func (lx *Lexer) ignore() {
+	lx.startOver()
+}
+
+// startOver resets the current lexeme, marking pos/line/col as the start
+// of whatever comes next.
+func (lx *Lexer) startOver() {
+	lx.start = lx.pos
+	lx.startLine, lx.startCol = lx.line, lx.col
+	lx.buf = lx.buf[:0]
+}
+
+func (lx *Lexer) backup() {
+	if lx.lastWidth == 0 {
+		return
+	}
+	rn := lx.buf[len(lx.buf)-1]
+	lx.buf = lx.buf[:len(lx.buf)-1]
+	lx.pos--
+	lx.line, lx.col = lx.prevLine, lx.prevCol
+	lx.backupRune = rn
+	lx.hasBackup = true
+	lx.lastWidth = 0
+}
+
+func (lx *Lexer) peek() rune {
+	rn := lx.next()
+	lx.backup()
+	return rn
+}
+
+func (lx *Lexer) accept(valid string) bool {
+	if strings.ContainsRune(valid, lx.next()) {
+		return true
+	}
+	lx.backup()
+	return false
+}
+
+func (lx *Lexer) acceptRun(valid string) {
+	for strings.ContainsRune(valid, lx.next()) {
+	}
+	lx.backup()
+}
+
+func (lx *Lexer) next() rune {
+	var rn rune
+	if lx.hasBackup {
+		rn = lx.backupRune
+		lx.hasBackup = false
+	} else {
+		r, _, err := lx.r.ReadRune()
+		if err != nil {
+			lx.lastWidth = 0
+			return eof
+		}
+		rn = r
+	}
+	lx.buf = append(lx.buf, rn)
+	lx.pos++
+	lx.lastWidth = 1
+
+	lx.recent = append(lx.recent, rn)
+	if len(lx.recent) > snippetWindow {
+		lx.recent = lx.recent[len(lx.recent)-snippetWindow:]
+	}
+
+	lx.prevLine, lx.prevCol = lx.line, lx.col
+	if rn == '\n' {
+		lx.line++
+		lx.col = 0
+	} else {
+		lx.col++
+	}
+	return rn
+}
+
+func (lx *Lexer) errorf(format string, args ...interface{}) stateFn {
+	lerr := &LexError{
+		Msg:     fmt.Sprintf(format, args...),
+		Offset:  lx.start,
+		Line:    lx.startLine,
+		Col:     lx.startCol + 1,
+		Snippet: strconv.Quote(string(lx.recent)),
+	}
+	lx.items <- Item{
+		Type:   ItemError,
+		Val:    lerr.Error(),
+		Offset: lx.start,
+		Line:   lx.startLine,
+		Col:    lx.startCol + 1,
+		Err:    lerr,
+	}
+	return nil
+}
+
+func lexNumber(lx *Lexer) stateFn {
+	// optional leading sign
+	lx.accept("+-")
+	// is it hex?
+	digits := "0123456789"
+	if lx.accept("0") && lx.accept("xX") {
+		digits = "0123456789abcdefABCDEF"
+	}
+	lx.acceptRun(digits)
+	if lx.accept(".") {
+		lx.acceptRun(digits)
+	}
+	if lx.accept("eE") {
+		lx.accept("+-")
+		lx.acceptRun("0123456789")
+	}
+	// is it imaginary?
+	lx.accept("i")
+	// next value must not be alphanumeric
+	pk := lx.peek()
+	if unicode.IsDigit(pk) || unicode.IsLetter(pk) {
+		lx.next()
+		return lx.errorf("bad number syntax %q", string(lx.buf))
+	}
+	if !hasDigit(lx.buf) {
+		return lx.errorf("bad number syntax %q", string(lx.buf))
+	}
+	lx.emit(ItemNumber)
+	return lx.afterValue()
+}
+
+// hasDigit reports whether buf contains at least one digit rune, so
+// lexNumber can reject input like a lone "-" that's otherwise well-formed
+// but carries no actual number.
+func hasDigit(buf []rune) bool {
+	for _, r := range buf {
+		if unicode.IsDigit(r) {
+			return true
+		}
+	}
+	return false
+}
+
+func lexSeparator(lx *Lexer) stateFn {
+	lx.emit(ItemSeparator)
+
+	for {
+		switch r := lx.next(); {
+		case r == eof:
+			return lx.errorf("unexpected EOF")
+		case r == stringStart:
+			return lexString
+		case r == openObject:
+			return lexOpenObject
+		case r == openArray:
+			return lexOpenArray
+		case unicode.IsDigit(r) || r == '-':
+			return lexNumber
+		case unicode.IsSpace(r):
+			lx.ignore()
+		case hasPrefixRune(r, tre):
+			return lexTrue
+		case hasPrefixRune(r, fls):
+			return lexFalse
+		case hasPrefixRune(r, nll):
+			return lexNull
+		default:
+			lx.errorf("unexpected symbol %s", string(r))
+		}
+	}
+}
+
+// lexValueStart reads the first rune of a value and dispatches to the
+// state that lexes it. It's the Lexer's initial state (so New and
+// NewStream both accept any JSON value at the top level, not just an
+// object), and it's how a stream Lexer picks the next document back up
+// once the previous one's ItemDocumentEnd has been emitted.
+func lexValueStart(lx *Lexer) stateFn {
+	for {
+		switch r := lx.next(); {
+		case r == eof:
+			lx.emit(ItemEOF)
+			return nil
+		case r == openObject:
+			return lexOpenObject
+		case r == openArray:
+			return lexOpenArray
+		case r == stringStart:
+			return lexString
+		case unicode.IsDigit(r) || r == '-':
+			return lexNumber
+		case unicode.IsSpace(r):
+			lx.ignore()
+		case hasPrefixRune(r, tre):
+			return lexTrue
+		case hasPrefixRune(r, fls):
+			return lexFalse
+		case hasPrefixRune(r, nll):
+			return lexNull
+		default:
+			return lx.errorf("unexpected symbol: %s", string(r))
+		}
+	}
+}
+
+func lexOpenObject(lx *Lexer) stateFn {
+	lx.emit(ItemObjectBegin)
+	lx.open = append(lx.open, openObject)
+
+	for {
+		switch r := lx.next(); {
+		case r == eof:
+			return lx.errorf("unclosed action") // TODO make better message
+		case r == stringStart:
+			return lexString
+		case r == closeObject:
+			return lexCloseObject
+		case unicode.IsSpace(r):
+			lx.ignore()
+		default:
+			return lx.errorf("expected string or '}'")
+		}
+	}
+}
+
+func lexCloseObject(lx *Lexer) stateFn {
+	if !lx.popContainer(openObject) {
+		return lx.errorf("mismatched '}'")
+	}
+	lx.emit(ItemObjectEnd)
+	return lx.afterValue()
+}
+
+func lexOpenArray(lx *Lexer) stateFn {
+	lx.emit(ItemArrayBegin)
+	lx.open = append(lx.open, openArray)
+	for {
+		switch r := lx.next(); {
+		case r == eof:
+			return lx.errorf("unexpected eof")
+		case r == openArray:
+			return lexOpenArray
+		case r == openObject:
+			return lexOpenObject
+		case r == closeArray:
+			return lexCloseArray
+		case r == stringStart:
+			return lexString
+		case unicode.IsDigit(r) || r == '-':
+			return lexNumber
+		case unicode.IsSpace(r):
+			lx.ignore()
+		case hasPrefixRune(r, tre):
+			return lexTrue
+		case hasPrefixRune(r, fls):
+			return lexFalse
+		case hasPrefixRune(r, nll):
+			return lexNull
+		default:
+			return lx.errorf("unexpected symbol: %s", string(r))
+		}
+	}
+}
+
+func lexNull(lx *Lexer) stateFn  { return lexKeyword(lx, nll, ItemNull) }
+func lexTrue(lx *Lexer) stateFn  { return lexKeyword(lx, tre, ItemBool) }
+func lexFalse(lx *Lexer) stateFn { return lexKeyword(lx, fls, ItemBool) }
+
+// lexKeyword consumes the remaining runes of word, whose first rune the
+// caller has already read and dispatched on via hasPrefixRune, and
+// errors as soon as one of them doesn't match rather than trusting that
+// a matching first rune means the rest of the keyword follows.
+func lexKeyword(lx *Lexer, word string, typ ItemType) stateFn {
+	for i := 1; i < len(word); i++ {
+		if r := lx.next(); r != rune(word[i]) {
+			return lx.errorf("invalid character %q in literal %s", r, word)
+		}
+	}
+	lx.emit(typ)
+	return lx.afterValue()
+}
+
+// popContainer pops the innermost open container and reports whether it
+// was want ('{' or '['), so a close bracket that doesn't match the
+// container it's actually closing (e.g. "[1, 2}") is a lex error instead
+// of being silently accepted.
+func (lx *Lexer) popContainer(want rune) bool {
+	n := len(lx.open)
+	if n == 0 || lx.open[n-1] != want {
+		return false
+	}
+	lx.open = lx.open[:n-1]
+	return true
+}
+
+// afterValue runs once a value - a scalar, or a just-closed object or
+// array - has been emitted, and decides what's legal next: a separator
+// or closing bracket while a container is still open, the end of input
+// at the top level, or, for a Lexer built with NewStream, the start of
+// another top-level document.
+func (lx *Lexer) afterValue() stateFn {
+	for {
+		switch r := lx.next(); {
+		case r == eof:
+			if len(lx.open) > 0 {
+				return lx.errorf("unexpected eof")
+			}
+			lx.emit(ItemEOF)
+			return nil
+		case r == separator:
+			if len(lx.open) == 0 {
+				return lx.errorf("unexpected symbol: %s", string(r))
+			}
+			return lexSeparator
+		case r == closeArray:
+			return lexCloseArray
+		case r == closeObject:
+			return lexCloseObject
+		case unicode.IsSpace(r):
+			lx.ignore()
+		default:
+			if lx.stream && len(lx.open) == 0 {
+				lx.backup()
+				lx.emit(ItemDocumentEnd)
+				return lexValueStart(lx)
+			}
+			return lx.errorf("unexpected symbol: %s", string(r))
+		}
+	}
+}
+
+func lexCloseArray(lx *Lexer) stateFn {
+	if !lx.popContainer(openArray) {
+		return lx.errorf("mismatched ']'")
+	}
+	lx.emit(ItemArrayEnd)
+	return lx.afterValue()
+}
+
+func lexString(lx *Lexer) stateFn {
+	var decoded strings.Builder
+	for {
+		switch r := lx.next(); {
+		case r == eof:
+			return lx.errorf("unexpected EOF")
+		case r == '\\':
+			if err := lx.decodeEscape(&decoded); err != nil {
+				return lx.errorf("%s", err)
+			}
+		case r == '\n' || r == '\r':
+			return lx.errorf("strings cannot contain newlines")
+		case r == stringEnd:
+			lx.emitString(decoded.String())
+			// A string can be an object key (followed by ':') or a
+			// plain value (followed by whatever afterValue expects);
+			// peek past any whitespace to tell which.
+			for {
+				switch r := lx.next(); {
+				case unicode.IsSpace(r):
+					lx.ignore()
+				case r == objectSeparator:
+					return lexColon
+				default:
+					lx.backup()
+					return lx.afterValue()
+				}
+			}
+		default:
+			decoded.WriteRune(r)
+		}
+	}
+}
+
+// decodeEscape consumes the character(s) following a backslash already
+// read by the caller and writes the escape's decoded rune(s) to decoded.
+// It handles the RFC 8259 short escapes and \uXXXX, combining a
+// surrogate pair into a single rune via utf16.DecodeRune.
+func (lx *Lexer) decodeEscape(decoded *strings.Builder) error {
+	switch e := lx.next(); e {
+	case '"', '\\', '/':
+		decoded.WriteRune(e)
+	case 'b':
+		decoded.WriteRune('\b')
+	case 'f':
+		decoded.WriteRune('\f')
+	case 'n':
+		decoded.WriteRune('\n')
+	case 'r':
+		decoded.WriteRune('\r')
+	case 't':
+		decoded.WriteRune('\t')
+	case 'u':
+		cp, err := lx.readHex4()
+		if err != nil {
+			return err
+		}
+		switch {
+		case isHighSurrogate(cp):
+			if lx.next() != '\\' || lx.next() != 'u' {
+				return fmt.Errorf("unpaired high surrogate \\u%04x", cp)
+			}
+			cp2, err := lx.readHex4()
+			if err != nil {
+				return err
+			}
+			if !isLowSurrogate(cp2) {
+				return fmt.Errorf("unpaired high surrogate \\u%04x", cp)
+			}
+			decoded.WriteRune(utf16.DecodeRune(rune(cp), rune(cp2)))
+		case isLowSurrogate(cp):
+			return fmt.Errorf("unpaired low surrogate \\u%04x", cp)
+		default:
+			decoded.WriteRune(rune(cp))
+		}
+	default:
+		return fmt.Errorf("invalid escape sequence \\%c", e)
+	}
+	return nil
+}
+
+// readHex4 reads the four hex digits of a \u escape and returns the code
+// unit they encode.
+func (lx *Lexer) readHex4() (uint16, error) {
+	var v uint16
+	for i := 0; i < 4; i++ {
+		d, ok := hexDigit(lx.next())
+		if !ok {
+			return 0, fmt.Errorf("invalid \\u escape")
+		}
+		v = v<<4 | d
+	}
+	return v, nil
+}
+
+func hexDigit(r rune) (uint16, bool) {
+	switch {
+	case r >= '0' && r <= '9':
+		return uint16(r - '0'), true
+	case r >= 'a' && r <= 'f':
+		return uint16(r-'a') + 10, true
+	case r >= 'A' && r <= 'F':
+		return uint16(r-'A') + 10, true
+	}
+	return 0, false
+}
+
+func isHighSurrogate(cp uint16) bool { return cp >= 0xD800 && cp <= 0xDBFF }
+func isLowSurrogate(cp uint16) bool  { return cp >= 0xDC00 && cp <= 0xDFFF }
+
+func lexColon(lx *Lexer) stateFn {
+	lx.emit(ItemColon)
+
+	for {
+		switch r := lx.next(); {
+		case r == eof:
+			return lx.errorf("unexpected eof")
+		case r == openArray:
+			return lexOpenArray
+		case r == openObject:
+			return lexOpenObject
+		case r == stringStart:
+			return lexString
+		case unicode.IsDigit(r) || r == '-':
+			return lexNumber
+		case unicode.IsSpace(r):
+			lx.ignore()
+		case hasPrefixRune(r, tre):
+			return lexTrue
+		case hasPrefixRune(r, fls):
+			return lexFalse
+		case hasPrefixRune(r, nll):
+			return lexNull
+		default:
+			return lx.errorf("unexpected symbol: %s", string(r))
+		}
+	}
+}
+
+// hasPrefixRune reports whether word starts with r. The old implementation
+// sliced the in-memory input to check multi-rune keywords like "true"; the
+// streaming lexer only has the single rune it just read, which is enough
+// since the JSON grammar makes the leading rune of true/false/null unique
+// among value starts.
+func hasPrefixRune(r rune, word string) bool {
+	return len(word) > 0 && rune(word[0]) == r
+}
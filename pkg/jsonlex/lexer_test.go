@@ -0,0 +1,278 @@
+package jsonlex
+
+import (
+	"strings"
+	"testing"
+)
+
+// items lexes doc to completion and returns every item, including a
+// trailing ItemError or ItemEOF if one was produced.
+func items(t *testing.T, doc string) []Item {
+	t.Helper()
+	return drain(t, New(strings.NewReader(doc)))
+}
+
+// streamItems lexes doc to completion with NewStream and returns every
+// item, including a trailing ItemError or ItemEOF.
+func streamItems(t *testing.T, doc string) []Item {
+	t.Helper()
+	return drain(t, NewStream(strings.NewReader(doc)))
+}
+
+func drain(t *testing.T, lx *Lexer) []Item {
+	t.Helper()
+	var got []Item
+	for {
+		it, ok := lx.NextItem()
+		if !ok {
+			return got
+		}
+		got = append(got, it)
+		if it.Type == ItemEOF || it.Type == ItemError {
+			return got
+		}
+	}
+}
+
+func lastString(t *testing.T, doc string) Item {
+	t.Helper()
+	var found Item
+	var ok bool
+	for _, it := range items(t, doc) {
+		if it.Type == ItemString {
+			found, ok = it, true
+		}
+	}
+	if !ok {
+		t.Fatalf("no string item lexed from %q", doc)
+	}
+	return found
+}
+
+func TestStringEscapes(t *testing.T) {
+	cases := []struct {
+		doc  string
+		want string
+	}{
+		{`{"k": "a\"b"}`, `a"b`},
+		{`{"k": "a\\b"}`, `a\b`},
+		{`{"k": "a\/b"}`, `a/b`},
+		{`{"k": "a\bb"}`, "a\bb"},
+		{`{"k": "a\fb"}`, "a\fb"},
+		{`{"k": "a\nb"}`, "a\nb"},
+		{`{"k": "a\rb"}`, "a\rb"},
+		{`{"k": "a\tb"}`, "a\tb"},
+		{`{"k": "café"}`, "café"},
+		{`{"k": "😀"}`, "😀"},
+		{`{"k": "\ud83d\ude00"}`, "\U0001F600"}, // surrogate pair escape, not a literal emoji
+	}
+	for _, c := range cases {
+		got := lastString(t, c.doc).Decoded
+		if got != c.want {
+			t.Errorf("decode %q: got %q, want %q", c.doc, got, c.want)
+		}
+	}
+}
+
+func TestStringRawRoundtrip(t *testing.T) {
+	it := lastString(t, `{"k": "a\nb"}`)
+	if it.Val != `"a\nb"` {
+		t.Errorf("raw Val = %q, want %q", it.Val, `"a\nb"`)
+	}
+}
+
+func TestUnpairedSurrogates(t *testing.T) {
+	docs := []string{
+		`{"k": "\ud83d"}`,   // high surrogate with no follow-up
+		`{"k": "\ude00"}`,   // bare low surrogate
+		`{"k": "\ud83dx"}`,  // high surrogate followed by a non-escape
+		`{"k": "\ud83d\t"}`, // high surrogate followed by a non-surrogate escape
+	}
+	for _, doc := range docs {
+		got := items(t, doc)
+		last := got[len(got)-1]
+		if last.Type != ItemError {
+			t.Errorf("doc %q: got %v, want a lex error", doc, last)
+		}
+	}
+}
+
+func TestRawNewlineInStringIsAnError(t *testing.T) {
+	doc := "{\"k\": \"a\nb\"}"
+	got := items(t, doc)
+	last := got[len(got)-1]
+	if last.Type != ItemError {
+		t.Errorf("raw newline in string: got %v, want a lex error", last)
+	}
+}
+
+func TestBadEscapeIsAnError(t *testing.T) {
+	got := items(t, `{"k": "a\qb"}`)
+	last := got[len(got)-1]
+	if last.Type != ItemError {
+		t.Errorf("bad escape: got %v, want a lex error", last)
+	}
+}
+
+func TestNegativeNumber(t *testing.T) {
+	got := items(t, `{"a": -12.5}`)
+	var num Item
+	for _, it := range got {
+		if it.Type == ItemNumber {
+			num = it
+		}
+	}
+	if num.Val != "-12.5" {
+		t.Errorf("got %v, want number -12.5", num)
+	}
+}
+
+func TestBareMinusIsAnError(t *testing.T) {
+	got := items(t, `{"a": [-, 1]}`)
+	last := got[len(got)-1]
+	if last.Type != ItemError {
+		t.Errorf("bare '-': got %v, want a lex error", last)
+	}
+}
+
+func TestMalformedKeywordIsAnError(t *testing.T) {
+	for _, doc := range []string{`{"a": trxyz}`, `{"a": flue}`, `{"a": nul}`} {
+		got := items(t, doc)
+		var sawBool, sawNull bool
+		for _, it := range got {
+			if it.Type == ItemBool || it.Type == ItemNull {
+				sawBool, sawNull = true, true
+			}
+		}
+		last := got[len(got)-1]
+		if last.Type != ItemError {
+			t.Errorf("New(%q): got %v, want an error for the malformed keyword", doc, last)
+		}
+		if sawBool || sawNull {
+			t.Errorf("New(%q): emitted a bool/null item for malformed input: %v", doc, got)
+		}
+	}
+}
+
+func TestItemPositions(t *testing.T) {
+	doc := "{\n  \"k\": 1\n}"
+	var got []Item
+	for _, it := range items(t, doc) {
+		if it.Type == ItemObjectBegin || it.Type == ItemString || it.Type == ItemNumber {
+			got = append(got, it)
+		}
+	}
+	want := []struct {
+		typ       ItemType
+		line, col int
+	}{
+		{ItemObjectBegin, 1, 1},
+		{ItemString, 2, 3},
+		{ItemNumber, 2, 8},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d positioned items, want %d: %v", len(got), len(want), got)
+	}
+	for i, w := range want {
+		if got[i].Type != w.typ || got[i].Line != w.line || got[i].Col != w.col {
+			t.Errorf("item %d: got %v at line %d col %d, want line %d col %d", i, got[i], got[i].Line, got[i].Col, w.line, w.col)
+		}
+	}
+}
+
+func TestLexErrorFormat(t *testing.T) {
+	doc := "{\n  \"k\": 12abc\n}"
+	got := items(t, doc)
+	last := got[len(got)-1]
+	if last.Type != ItemError {
+		t.Fatalf("got %v, want a lex error", last)
+	}
+	if last.Err == nil {
+		t.Fatalf("Item.Err is nil for an ItemError")
+	}
+	if last.Err.Line != 2 || last.Err.Col != 8 {
+		t.Errorf("got line %d col %d, want line 2 col 8", last.Err.Line, last.Err.Col)
+	}
+	want := `line 2 col 8: bad number syntax "12a"`
+	if last.Err.Error() != want {
+		t.Errorf("Error() = %q, want %q", last.Err.Error(), want)
+	}
+}
+
+func TestTopLevelScalar(t *testing.T) {
+	for _, doc := range []string{`42`, `"hi"`, `true`, `null`, `[1, 2]`} {
+		got := items(t, doc)
+		last := got[len(got)-1]
+		if last.Type != ItemEOF {
+			t.Errorf("New(%q): got %v, want a clean ItemEOF", doc, last)
+		}
+	}
+}
+
+func TestNDJSON(t *testing.T) {
+	got := streamItems(t, "{\"a\": 1}\n{\"b\": 2}\n")
+	var types []ItemType
+	for _, it := range got {
+		types = append(types, it.Type)
+	}
+	want := []ItemType{
+		ItemObjectBegin, ItemString, ItemColon, ItemNumber, ItemObjectEnd,
+		ItemDocumentEnd,
+		ItemObjectBegin, ItemString, ItemColon, ItemNumber, ItemObjectEnd,
+		ItemEOF,
+	}
+	if len(types) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i, w := range want {
+		if types[i] != w {
+			t.Errorf("item %d: got type %v, want %v", i, types[i], w)
+		}
+	}
+}
+
+func TestStreamConcatenatedDocuments(t *testing.T) {
+	// No whitespace at all between documents of mixed top-level types.
+	got := streamItems(t, `{"a":1}[1,2]"x" true null 7`)
+	var ends int
+	for _, it := range got {
+		if it.Type == ItemDocumentEnd {
+			ends++
+		}
+	}
+	if ends != 5 {
+		t.Fatalf("got %d ItemDocumentEnd, want 5 (one between each of 6 documents): %v", ends, got)
+	}
+	last := got[len(got)-1]
+	if last.Type != ItemEOF {
+		t.Fatalf("got %v, want a clean ItemEOF", last)
+	}
+}
+
+func TestNonStreamStopsAtFirstDocument(t *testing.T) {
+	// Without NewStream, trailing content after the first value is an
+	// error rather than the start of a second document.
+	got := items(t, `{"a": 1}{"b": 2}`)
+	last := got[len(got)-1]
+	if last.Type != ItemError {
+		t.Errorf("New: got %v, want an error on trailing content", last)
+	}
+}
+
+func TestMismatchedBracketIsAnError(t *testing.T) {
+	for _, doc := range []string{`{"a": 1]`, `{}]`, `[1, 2}`} {
+		got := items(t, doc)
+		last := got[len(got)-1]
+		if last.Type != ItemError {
+			t.Errorf("New(%q): got %v, want an error for the mismatched close", doc, last)
+		}
+	}
+}
+
+func TestStreamUnclosedContainerIsAnError(t *testing.T) {
+	got := streamItems(t, `{"a": [1, 2}`)
+	last := got[len(got)-1]
+	if last.Type != ItemError {
+		t.Errorf("got %v, want an error for the mismatched close", last)
+	}
+}
@@ -0,0 +1,400 @@
+// Package jsonparse builds Go values from a jsonlex token stream. It
+// offers the same two layers as encoding/json: a Decoder.Decode that
+// assembles a full value (a generic map[string]any/[]any tree, or a
+// caller's struct filled via reflection and `json` tags), and a
+// Decoder.Token that walks the stream one token at a time so large
+// documents can be processed without allocating the whole tree.
+package jsonparse
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/tskinn/cf-lsp/pkg/jsonlex"
+)
+
+// Decoder reads a sequence of JSON values from an underlying jsonlex
+// token stream, mirroring the shape of encoding/json.Decoder.
+type Decoder struct {
+	lx     *jsonlex.Lexer
+	peeked *jsonlex.Item
+	err    error // sticky once the stream has errored or ended
+}
+
+// NewDecoder returns a Decoder that reads JSON from r.
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{lx: jsonlex.New(r)}
+}
+
+// rawItem returns the next item off the lexer, translating ItemError into
+// the lexer's LexError and ItemEOF into io.EOF so callers can use the
+// usual Go stream-exhaustion check.
+func (d *Decoder) rawItem() (jsonlex.Item, error) {
+	if d.err != nil {
+		return jsonlex.Item{}, d.err
+	}
+	var it jsonlex.Item
+	if d.peeked != nil {
+		it, d.peeked = *d.peeked, nil
+	} else {
+		ok := false
+		if it, ok = d.lx.NextItem(); !ok {
+			d.err = io.EOF
+			return jsonlex.Item{}, d.err
+		}
+	}
+	switch it.Type {
+	case jsonlex.ItemError:
+		d.err = it.Err
+		return jsonlex.Item{}, d.err
+	case jsonlex.ItemEOF:
+		d.err = io.EOF
+		return jsonlex.Item{}, d.err
+	}
+	return it, nil
+}
+
+// peekItem returns the next item without consuming it.
+func (d *Decoder) peekItem() (jsonlex.Item, error) {
+	it, err := d.rawItem()
+	if err != nil {
+		return it, err
+	}
+	d.peeked = &it
+	return it, nil
+}
+
+// More reports whether there is another element before the next closing
+// '}' or ']', analogous to encoding/json.Decoder.More. It's meant to
+// guard a loop that alternates Token and Decode calls while walking a
+// large array or object without materializing it.
+func (d *Decoder) More() bool {
+	it, err := d.peekItem()
+	if err != nil {
+		return false
+	}
+	return it.Type != jsonlex.ItemObjectEnd && it.Type != jsonlex.ItemArrayEnd
+}
+
+// Token returns the next token in the input stream: a Delim for '{', '}',
+// '[', ']', or a Number, String, Bool, or Null for a scalar value. Commas
+// and colons are consumed but never returned, the same way
+// encoding/json.Decoder.Token hides them. It returns io.EOF once the
+// stream is exhausted.
+func (d *Decoder) Token() (Token, error) {
+	for {
+		it, err := d.rawItem()
+		if err != nil {
+			return nil, err
+		}
+		switch it.Type {
+		case jsonlex.ItemColon, jsonlex.ItemSeparator:
+			continue
+		case jsonlex.ItemObjectBegin:
+			return Delim('{'), nil
+		case jsonlex.ItemObjectEnd:
+			return Delim('}'), nil
+		case jsonlex.ItemArrayBegin:
+			return Delim('['), nil
+		case jsonlex.ItemArrayEnd:
+			return Delim(']'), nil
+		case jsonlex.ItemString:
+			return String(it.Decoded), nil
+		case jsonlex.ItemNumber:
+			return Number(it.Val), nil
+		case jsonlex.ItemBool:
+			return Bool(it.Val == "true"), nil
+		case jsonlex.ItemNull:
+			return Null{}, nil
+		default:
+			return nil, fmt.Errorf("jsonparse: unexpected token type %v", it.Type)
+		}
+	}
+}
+
+// Decode reads the next complete JSON value from the stream and stores it
+// in v, which must be a non-nil pointer. If v points at an interface (for
+// example *any), Decode builds the package's generic tree out of
+// map[string]any, []any, string, Number, bool, and nil. Otherwise it uses
+// reflection to fill v, matching object keys against struct fields by
+// their `json` tag or, failing that, the field name.
+func (d *Decoder) Decode(v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return fmt.Errorf("jsonparse: Decode requires a non-nil pointer, got %T", v)
+	}
+	val, err := d.decodeValue()
+	if err != nil {
+		return err
+	}
+	return assign(rv.Elem(), val)
+}
+
+// decodeValue reads one complete value from the token stream and
+// assembles it as the generic tree described on Decode.
+func (d *Decoder) decodeValue() (any, error) {
+	tok, err := d.Token()
+	if err != nil {
+		return nil, err
+	}
+	return d.valueFrom(tok)
+}
+
+func (d *Decoder) valueFrom(tok Token) (any, error) {
+	switch t := tok.(type) {
+	case Delim:
+		switch rune(t) {
+		case '{':
+			return d.decodeObject()
+		case '[':
+			return d.decodeArray()
+		default:
+			return nil, fmt.Errorf("jsonparse: unexpected delimiter %q", t)
+		}
+	case Number:
+		return t, nil
+	case String:
+		return string(t), nil
+	case Bool:
+		return bool(t), nil
+	case Null:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("jsonparse: unexpected token %v", tok)
+	}
+}
+
+func (d *Decoder) decodeObject() (map[string]any, error) {
+	obj := make(map[string]any)
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return nil, err
+		}
+		if tok == Delim('}') {
+			return obj, nil
+		}
+		key, ok := tok.(String)
+		if !ok {
+			return nil, fmt.Errorf("jsonparse: expected object key, got %v", tok)
+		}
+		val, err := d.decodeValue()
+		if err != nil {
+			return nil, err
+		}
+		obj[string(key)] = val
+	}
+}
+
+func (d *Decoder) decodeArray() ([]any, error) {
+	var arr []any
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return nil, err
+		}
+		if tok == Delim(']') {
+			return arr, nil
+		}
+		val, err := d.valueFrom(tok)
+		if err != nil {
+			return nil, err
+		}
+		arr = append(arr, val)
+	}
+}
+
+var numberType = reflect.TypeOf(Number(""))
+
+// assign stores the generic value val (as produced by decodeValue) into
+// rv, converting between JSON's loose value set and Go's stricter typing
+// using reflection. It allocates through pointers and unkeyed interfaces
+// as needed, the way encoding/json's Unmarshal does.
+func assign(rv reflect.Value, val any) error {
+	if val == nil {
+		switch rv.Kind() {
+		case reflect.Pointer, reflect.Interface, reflect.Slice, reflect.Map:
+			rv.Set(reflect.Zero(rv.Type()))
+		}
+		return nil
+	}
+
+	if rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			rv.Set(reflect.New(rv.Type().Elem()))
+		}
+		return assign(rv.Elem(), val)
+	}
+
+	if rv.Kind() == reflect.Interface && rv.NumMethod() == 0 {
+		rv.Set(reflect.ValueOf(val))
+		return nil
+	}
+
+	if n, ok := val.(Number); ok && rv.Type() == numberType {
+		rv.SetString(string(n))
+		return nil
+	}
+
+	switch v := val.(type) {
+	case map[string]any:
+		return assignObject(rv, v)
+	case []any:
+		return assignArray(rv, v)
+	case string:
+		if rv.Kind() != reflect.String {
+			return fmt.Errorf("jsonparse: cannot assign string into %s", rv.Type())
+		}
+		rv.SetString(v)
+	case bool:
+		if rv.Kind() != reflect.Bool {
+			return fmt.Errorf("jsonparse: cannot assign bool into %s", rv.Type())
+		}
+		rv.SetBool(v)
+	case Number:
+		return assignNumber(rv, v)
+	default:
+		return fmt.Errorf("jsonparse: cannot assign %T into %s", val, rv.Type())
+	}
+	return nil
+}
+
+func assignNumber(rv reflect.Value, n Number) error {
+	// Int/uint fields parse the decimal text directly rather than
+	// round-tripping through float64, which loses precision past 2^53
+	// (see the Number doc comment in token.go).
+	switch rv.Kind() {
+	case reflect.Float32, reflect.Float64:
+		f, err := n.Float64()
+		if err != nil {
+			return fmt.Errorf("jsonparse: %w", err)
+		}
+		rv.SetFloat(f)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(string(n), 10, 64)
+		if err != nil {
+			return fmt.Errorf("jsonparse: %w", err)
+		}
+		rv.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(string(n), 10, 64)
+		if err != nil {
+			return fmt.Errorf("jsonparse: %w", err)
+		}
+		rv.SetUint(u)
+	default:
+		return fmt.Errorf("jsonparse: cannot assign number into %s", rv.Type())
+	}
+	return nil
+}
+
+func assignArray(rv reflect.Value, arr []any) error {
+	if rv.Kind() != reflect.Slice {
+		return fmt.Errorf("jsonparse: cannot assign array into %s", rv.Type())
+	}
+	out := reflect.MakeSlice(rv.Type(), len(arr), len(arr))
+	for i, elem := range arr {
+		if err := assign(out.Index(i), elem); err != nil {
+			return err
+		}
+	}
+	rv.Set(out)
+	return nil
+}
+
+func assignObject(rv reflect.Value, obj map[string]any) error {
+	switch rv.Kind() {
+	case reflect.Map:
+		if rv.IsNil() {
+			rv.Set(reflect.MakeMapWithSize(rv.Type(), len(obj)))
+		}
+		for k, v := range obj {
+			elem := reflect.New(rv.Type().Elem()).Elem()
+			if err := assign(elem, v); err != nil {
+				return err
+			}
+			key, err := mapKey(k, rv.Type().Key())
+			if err != nil {
+				return err
+			}
+			rv.SetMapIndex(key, elem)
+		}
+		return nil
+
+	case reflect.Struct:
+		fields := fieldsByTag(rv.Type())
+		for k, v := range obj {
+			idx, ok := fields[strings.ToLower(k)]
+			if !ok {
+				continue
+			}
+			if err := assign(rv.Field(idx), v); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	default:
+		return fmt.Errorf("jsonparse: cannot assign object into %s", rv.Type())
+	}
+}
+
+// mapKey converts a decoded object key, always a string, into a
+// reflect.Value of keyType, the way assignNumber parses a Number's decimal
+// text for non-float destinations instead of going through a lossy
+// intermediate conversion.
+func mapKey(k string, keyType reflect.Type) (reflect.Value, error) {
+	switch keyType.Kind() {
+	case reflect.String:
+		return reflect.ValueOf(k).Convert(keyType), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(k, 10, 64)
+		if err != nil {
+			return reflect.Value{}, fmt.Errorf("jsonparse: bad map key %q for %s: %w", k, keyType, err)
+		}
+		v := reflect.New(keyType).Elem()
+		v.SetInt(i)
+		return v, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(k, 10, 64)
+		if err != nil {
+			return reflect.Value{}, fmt.Errorf("jsonparse: bad map key %q for %s: %w", k, keyType, err)
+		}
+		v := reflect.New(keyType).Elem()
+		v.SetUint(u)
+		return v, nil
+	default:
+		return reflect.Value{}, fmt.Errorf("jsonparse: cannot assign object into map with key type %s", keyType)
+	}
+}
+
+// fieldsByTag maps each exported field of t to its index, keyed by the
+// lowercased name it's addressed by: its `json` tag if it has one,
+// otherwise its Go field name. This lets object keys match
+// case-insensitively the way encoding/json does.
+func fieldsByTag(t reflect.Type) map[string]int {
+	out := make(map[string]int, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue // unexported
+		}
+		name := f.Name
+		if tag := f.Tag.Get("json"); tag != "" {
+			if comma := strings.IndexByte(tag, ','); comma >= 0 {
+				tag = tag[:comma]
+			}
+			if tag == "-" {
+				continue
+			}
+			if tag != "" {
+				name = tag
+			}
+		}
+		out[strings.ToLower(name)] = i
+	}
+	return out
+}
@@ -0,0 +1,146 @@
+package jsonparse
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestDecodeGenericTree(t *testing.T) {
+	var v any
+	err := NewDecoder(strings.NewReader(`{"name": "ada", "age": 36, "admin": true, "tags": ["a", "b"], "note": null}`)).Decode(&v)
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	obj, ok := v.(map[string]any)
+	if !ok {
+		t.Fatalf("Decode produced %T, want map[string]any", v)
+	}
+	if obj["name"] != "ada" {
+		t.Errorf("name = %v, want ada", obj["name"])
+	}
+	age, ok := obj["age"].(Number)
+	if !ok {
+		t.Fatalf("age = %T, want Number", obj["age"])
+	}
+	if f, _ := age.Float64(); f != 36 {
+		t.Errorf("age = %v, want 36", f)
+	}
+	if obj["admin"] != true {
+		t.Errorf("admin = %v, want true", obj["admin"])
+	}
+	tags, ok := obj["tags"].([]any)
+	if !ok || len(tags) != 2 || tags[0] != "a" || tags[1] != "b" {
+		t.Errorf("tags = %v, want [a b]", obj["tags"])
+	}
+	if obj["note"] != nil {
+		t.Errorf("note = %v, want nil", obj["note"])
+	}
+}
+
+func TestDecodeStruct(t *testing.T) {
+	type person struct {
+		Name string `json:"name"`
+		Age  int    `json:"age"`
+		Tags []string
+	}
+
+	var p person
+	err := NewDecoder(strings.NewReader(`{"name": "ada", "age": 36, "tags": ["x", "y"]}`)).Decode(&p)
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	want := person{Name: "ada", Age: 36, Tags: []string{"x", "y"}}
+	if p.Name != want.Name || p.Age != want.Age || len(p.Tags) != 2 || p.Tags[0] != want.Tags[0] || p.Tags[1] != want.Tags[1] {
+		t.Errorf("Decode = %+v, want %+v", p, want)
+	}
+}
+
+func TestDecodeLargeInt64(t *testing.T) {
+	type record struct {
+		ID int64 `json:"id"`
+	}
+	var r record
+	err := NewDecoder(strings.NewReader(`{"id": 9223372036854775807}`)).Decode(&r)
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if r.ID != 9223372036854775807 {
+		t.Errorf("ID = %d, want 9223372036854775807", r.ID)
+	}
+}
+
+func TestToken(t *testing.T) {
+	dec := NewDecoder(strings.NewReader(`{"a": [1, -2.5, false]}`))
+	var got []Token
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Token: %v", err)
+		}
+		got = append(got, tok)
+	}
+	want := []Token{Delim('{'), String("a"), Delim('['), Number("1"), Number("-2.5"), Bool(false), Delim(']'), Delim('}')}
+	if len(got) != len(want) {
+		t.Fatalf("Token sequence = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("token %d = %#v, want %#v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestDecodeMap(t *testing.T) {
+	var m map[string]int
+	err := NewDecoder(strings.NewReader(`{"a": 1, "b": 2}`)).Decode(&m)
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if m["a"] != 1 || m["b"] != 2 || len(m) != 2 {
+		t.Errorf("Decode = %v, want map[a:1 b:2]", m)
+	}
+}
+
+func TestDecodeIntKeyedMap(t *testing.T) {
+	var m map[int]string
+	err := NewDecoder(strings.NewReader(`{"1": "a", "2": "b"}`)).Decode(&m)
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if m[1] != "a" || m[2] != "b" || len(m) != 2 {
+		t.Errorf("Decode = %v, want map[1:a 2:b]", m)
+	}
+}
+
+func TestDecodeUnsupportedMapKeyIsAnError(t *testing.T) {
+	var m map[bool]string
+	err := NewDecoder(strings.NewReader(`{"1": "a"}`)).Decode(&m)
+	if err == nil {
+		t.Fatalf("Decode: want an error for a bool-keyed map, got nil")
+	}
+}
+
+func TestDecodeMore(t *testing.T) {
+	// Reach the array to walk by consuming the tokens that precede it.
+	dec := NewDecoder(strings.NewReader(`{"nums": [1, 2, 3]}`))
+	for _, want := range []Token{Delim('{'), String("nums"), Delim('[')} {
+		if tok, err := dec.Token(); err != nil || tok != want {
+			t.Fatalf("Token: %v, %v, want %v", tok, err, want)
+		}
+	}
+	var nums []Number
+	for dec.More() {
+		var n Number
+		if err := dec.Decode(&n); err != nil {
+			t.Fatalf("Decode: %v", err)
+		}
+		nums = append(nums, n)
+	}
+	if len(nums) != 3 {
+		t.Fatalf("decoded %d numbers, want 3", len(nums))
+	}
+}
@@ -0,0 +1,45 @@
+package jsonparse
+
+import "strconv"
+
+// Token is the interface implemented by every value a Decoder.Token call
+// can return: Delim, Number, String, Bool, or Null.
+type Token interface {
+	isToken()
+}
+
+// Delim is one of the four structural characters '{', '}', '[', ']'.
+type Delim rune
+
+func (Delim) isToken() {}
+
+func (d Delim) String() string { return string(rune(d)) }
+
+// Number is a JSON number in its original decimal text, so callers can
+// choose how to parse it (float64, big.Rat, etc.) instead of losing
+// precision to an eager float64 conversion.
+type Number string
+
+func (Number) isToken() {}
+
+// Float64 parses the number as a float64.
+func (n Number) Float64() (float64, error) {
+	return strconv.ParseFloat(string(n), 64)
+}
+
+func (n Number) String() string { return string(n) }
+
+// String is a decoded JSON string value.
+type String string
+
+func (String) isToken() {}
+
+// Bool is a JSON true/false value.
+type Bool bool
+
+func (Bool) isToken() {}
+
+// Null is the JSON null value.
+type Null struct{}
+
+func (Null) isToken() {}
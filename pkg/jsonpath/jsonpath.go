@@ -0,0 +1,253 @@
+// Package jsonpath evaluates JSONPath queries directly against a
+// jsonlex token stream, so a match is delivered as soon as its value has
+// been fully read without ever materializing the surrounding document.
+package jsonpath
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/tskinn/cf-lsp/pkg/jsonlex"
+)
+
+// Match is a single value selected by a query, along with the concrete
+// path it was found at (recursive descent and wildcards resolve to the
+// exact location of this particular match).
+type Match struct {
+	Path string
+	Raw  []byte
+}
+
+// segKind identifies what a location segment addresses.
+type segKind int
+
+const (
+	segObject segKind = iota
+	segArray
+)
+
+// segment is one entry of the location stack: either the key of the
+// object currently being read, or the index of the array currently being
+// read.
+type segment struct {
+	kind segKind
+	key  string
+	idx  int
+
+	awaitingKey bool // object only: true when the next string item is a key, not a value
+}
+
+// Evaluator walks a jsonlex token stream against a single parsed query.
+type Evaluator struct {
+	ops []operator
+}
+
+// New parses query and returns an Evaluator that can be run against any
+// number of token streams.
+func New(query string) (*Evaluator, error) {
+	ops, err := parse(query)
+	if err != nil {
+		return nil, err
+	}
+	return &Evaluator{ops: ops}, nil
+}
+
+// capture tracks an in-flight match whose value spans more than one item
+// (an object or array), accumulating its raw reconstruction until the
+// closing brace/bracket balances back out.
+type capture struct {
+	path  string
+	buf   strings.Builder
+	depth int
+}
+
+// Run tokenizes from lx and calls fn with the path and raw JSON bytes of
+// each value that matches the query. It returns any lexing error.
+func (e *Evaluator) Run(lx *jsonlex.Lexer, fn func(path string, raw []byte)) error {
+	var loc []segment
+	var captures []*capture
+	awaitingValue := false // true once the current location has matched and we're waiting for its value token
+
+	deliver := func(path string, raw []byte) {
+		fn(path, raw)
+	}
+
+	for {
+		item, ok := lx.NextItem()
+		if !ok {
+			return nil
+		}
+		if item.Type == jsonlex.ItemError {
+			return fmt.Errorf("jsonpath: %s", item.Val)
+		}
+		if item.Type == jsonlex.ItemEOF {
+			return nil
+		}
+
+		// Feed every open capture this item before acting on it, so
+		// nested begin/end tokens are both recorded and counted.
+		for i := 0; i < len(captures); {
+			c := captures[i]
+			c.buf.WriteString(rawOf(item))
+			switch item.Type {
+			case jsonlex.ItemObjectBegin, jsonlex.ItemArrayBegin:
+				c.depth++
+			case jsonlex.ItemObjectEnd, jsonlex.ItemArrayEnd:
+				c.depth--
+			}
+			if c.depth == 0 {
+				deliver(c.path, []byte(c.buf.String()))
+				captures = append(captures[:i], captures[i+1:]...)
+				continue
+			}
+			i++
+		}
+
+		if awaitingValue {
+			awaitingValue = false
+			path := locPath(loc)
+			switch item.Type {
+			case jsonlex.ItemObjectBegin, jsonlex.ItemArrayBegin:
+				c := &capture{path: path, depth: 1}
+				c.buf.WriteString(rawOf(item))
+				captures = append(captures, c)
+			case jsonlex.ItemObjectEnd, jsonlex.ItemArrayEnd:
+				// an empty array: there was no element to match
+			default:
+				deliver(path, []byte(rawOf(item)))
+			}
+		}
+
+		switch item.Type {
+		case jsonlex.ItemObjectBegin:
+			loc = append(loc, segment{kind: segObject, awaitingKey: true})
+
+		case jsonlex.ItemArrayBegin:
+			loc = append(loc, segment{kind: segArray, idx: 0})
+			if matches(e.ops, loc) {
+				awaitingValue = true
+			}
+
+		case jsonlex.ItemObjectEnd, jsonlex.ItemArrayEnd:
+			if len(loc) > 0 {
+				loc = loc[:len(loc)-1]
+			}
+
+		case jsonlex.ItemColon:
+			if top := topOf(loc); top != nil {
+				if matches(e.ops, loc) {
+					awaitingValue = true
+				}
+			}
+
+		case jsonlex.ItemSeparator:
+			if top := topOf(loc); top != nil && top.kind == segArray {
+				top.idx++
+				if matches(e.ops, loc) {
+					awaitingValue = true
+				}
+			} else if top != nil {
+				top.awaitingKey = true
+			}
+
+		case jsonlex.ItemString:
+			if top := topOf(loc); top != nil && top.kind == segObject && top.awaitingKey {
+				top.key = item.Decoded
+				top.awaitingKey = false
+			}
+		}
+	}
+}
+
+// topOf returns a pointer to the top of the location stack, or nil if
+// it's empty, so callers can mutate it in place.
+func topOf(loc []segment) *segment {
+	if len(loc) == 0 {
+		return nil
+	}
+	return &loc[len(loc)-1]
+}
+
+// rawOf returns the literal JSON text an item contributes to a
+// reconstructed value. Whitespace between tokens isn't preserved by
+// jsonlex, so captured values are re-serialized compactly rather than
+// byte-identical to the source.
+func rawOf(item jsonlex.Item) string {
+	switch item.Type {
+	case jsonlex.ItemObjectBegin:
+		return "{"
+	case jsonlex.ItemObjectEnd:
+		return "}"
+	case jsonlex.ItemArrayBegin:
+		return "["
+	case jsonlex.ItemArrayEnd:
+		return "]"
+	case jsonlex.ItemColon:
+		return ":"
+	case jsonlex.ItemSeparator:
+		return ","
+	default:
+		return item.Val
+	}
+}
+
+// locPath renders loc as a JSONPath-style string, e.g. "$.store.book[0]".
+func locPath(loc []segment) string {
+	var b strings.Builder
+	b.WriteByte('$')
+	for _, seg := range loc {
+		if seg.kind == segObject {
+			b.WriteByte('.')
+			b.WriteString(seg.key)
+		} else {
+			b.WriteByte('[')
+			b.WriteString(strconv.Itoa(seg.idx))
+			b.WriteByte(']')
+		}
+	}
+	return b.String()
+}
+
+// matches reports whether loc is exactly the location addressed by ops,
+// i.e. ops fully consumes loc and loc fully satisfies ops.
+func matches(ops []operator, loc []segment) bool {
+	return matchFrom(ops, 0, loc, 0)
+}
+
+func matchFrom(ops []operator, i int, loc []segment, j int) bool {
+	if i == len(ops) {
+		return j == len(loc)
+	}
+	op := ops[i]
+	switch op.kind {
+	case opName:
+		if j < len(loc) && loc[j].kind == segObject && loc[j].key == op.name {
+			return matchFrom(ops, i+1, loc, j+1)
+		}
+		return false
+
+	case opNameWild:
+		if j < len(loc) {
+			return matchFrom(ops, i+1, loc, j+1)
+		}
+		return false
+
+	case opIndexRange:
+		if j < len(loc) && loc[j].kind == segArray && op.inRange(loc[j].idx) {
+			return matchFrom(ops, i+1, loc, j+1)
+		}
+		return false
+
+	case opRecursiveDescent:
+		for k := j; k < len(loc); k++ {
+			if loc[k].kind == segObject && loc[k].key == op.name {
+				if matchFrom(ops, i+1, loc, k+1) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	return false
+}
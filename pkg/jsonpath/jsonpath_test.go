@@ -0,0 +1,123 @@
+package jsonpath
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/tskinn/cf-lsp/pkg/jsonlex"
+)
+
+const doc = `{"store":{"book":[{"author":"a1","price":10},{"author":"a2","price":20}],"bicycle":{"color":"red","price":19.95}}}`
+
+// match runs query against doc and returns "path=raw" strings in the
+// order the evaluator delivered them.
+func match(t *testing.T, query string) []string {
+	t.Helper()
+	ev, err := New(query)
+	if err != nil {
+		t.Fatalf("New(%q): %v", query, err)
+	}
+	var got []string
+	err = ev.Run(jsonlex.New(strings.NewReader(doc)), func(path string, raw []byte) {
+		got = append(got, path+"="+string(raw))
+	})
+	if err != nil {
+		t.Fatalf("Run(%q): %v", query, err)
+	}
+	return got
+}
+
+func assertMatches(t *testing.T, query string, want ...string) {
+	t.Helper()
+	got := match(t, query)
+	if len(got) != len(want) {
+		t.Fatalf("%s: got %v, want %v", query, got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("%s: match %d = %q, want %q", query, i, got[i], want[i])
+		}
+	}
+}
+
+func TestNameMatch(t *testing.T) {
+	assertMatches(t, "$.store.bicycle.color", `$.store.bicycle.color="red"`)
+}
+
+func TestIndexMatch(t *testing.T) {
+	assertMatches(t, "$.store.book[0].author", `$.store.book[0].author="a1"`)
+	assertMatches(t, "$.store.book[1].price", `$.store.book[1].price=20`)
+}
+
+func TestWildcardMatch(t *testing.T) {
+	assertMatches(t, "$.store.book[*].author",
+		`$.store.book[0].author="a1"`,
+		`$.store.book[1].author="a2"`,
+	)
+}
+
+func TestRecursiveDescentMatch(t *testing.T) {
+	assertMatches(t, "$..price",
+		`$.store.book[0].price=10`,
+		`$.store.book[1].price=20`,
+		`$.store.bicycle.price=19.95`,
+	)
+}
+
+func TestSliceMatch(t *testing.T) {
+	assertMatches(t, "$.store.book[0:1].author", `$.store.book[0].author="a1"`)
+	assertMatches(t, "$.store.book[1:].author", `$.store.book[1].author="a2"`)
+}
+
+func TestOpenEndedSliceMatchesEverythingFromStart(t *testing.T) {
+	ev, err := New("$.a[1:]")
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	var got []string
+	err = ev.Run(jsonlex.New(strings.NewReader(`{"a":[10,20,30,40]}`)), func(path string, raw []byte) {
+		got = append(got, path+"="+string(raw))
+	})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	want := []string{"$.a[1]=20", "$.a[2]=30", "$.a[3]=40"}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("match %d: got %q, want %q", i, got[i], want[i])
+		}
+	}
+}
+
+func TestObjectCapture(t *testing.T) {
+	assertMatches(t, "$.store.book[0]", `$.store.book[0]={"author":"a1","price":10}`)
+}
+
+func TestArrayCapture(t *testing.T) {
+	assertMatches(t, "$.store.book", `$.store.book=[{"author":"a1","price":10},{"author":"a2","price":20}]`)
+}
+
+func TestNoMatch(t *testing.T) {
+	got := match(t, "$.store.warehouse")
+	if len(got) != 0 {
+		t.Errorf("got %v, want no matches", got)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	cases := []string{
+		"store.book",    // missing leading '$'
+		"$..",           // recursive descent with no name
+		"$.book[",       // unterminated '['
+		"$.book[abc]",   // bad index
+		"$.book[1:abc]", // bad slice end
+	}
+	for _, q := range cases {
+		if _, err := New(q); err == nil {
+			t.Errorf("New(%q): want an error, got nil", q)
+		}
+	}
+}
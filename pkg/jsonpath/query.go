@@ -0,0 +1,144 @@
+package jsonpath
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// opKind identifies how an operator matches a path segment.
+type opKind int
+
+const (
+	opName             opKind = iota // .foo or ["foo"]
+	opNameWild                       // .* or [*]
+	opRecursiveDescent               // ..foo, matches "foo" at any depth
+	opIndexRange                     // [n] or [start:end]
+)
+
+// operator is a single step of a parsed JSONPath query.
+type operator struct {
+	kind opKind
+	name string // for opName and opRecursiveDescent
+
+	start     int // for opIndexRange
+	end       int
+	hasEnd    bool
+	openEnded bool // slice with no end, e.g. [2:]: matches every idx >= start
+}
+
+func (op operator) inRange(idx int) bool {
+	if idx < op.start {
+		return false
+	}
+	if op.openEnded {
+		return true
+	}
+	if !op.hasEnd {
+		return idx == op.start
+	}
+	return idx < op.end
+}
+
+// parse turns a query such as "$.store.book[*].author", "$..price" or
+// "$.items[0:5]" into a sequence of operators to walk against a location
+// stack produced while tokenizing a JSON document.
+func parse(query string) ([]operator, error) {
+	if !strings.HasPrefix(query, "$") {
+		return nil, fmt.Errorf("jsonpath: query must start with '$': %q", query)
+	}
+	rest := query[1:]
+	var ops []operator
+
+	for len(rest) > 0 {
+		switch {
+		case strings.HasPrefix(rest, ".."):
+			rest = rest[2:]
+			name, n := readName(rest)
+			rest = rest[n:]
+			if name == "" {
+				return nil, fmt.Errorf("jsonpath: %q: recursive descent requires a name", query)
+			}
+			ops = append(ops, operator{kind: opRecursiveDescent, name: name})
+
+		case rest[0] == '.':
+			rest = rest[1:]
+			name, n := readName(rest)
+			rest = rest[n:]
+			if name == "" {
+				return nil, fmt.Errorf("jsonpath: %q: expected a name after '.'", query)
+			}
+			if name == "*" {
+				ops = append(ops, operator{kind: opNameWild})
+			} else {
+				ops = append(ops, operator{kind: opName, name: name})
+			}
+
+		case rest[0] == '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("jsonpath: %q: unterminated '['", query)
+			}
+			inner := rest[1:end]
+			rest = rest[end+1:]
+			op, err := parseBracket(inner)
+			if err != nil {
+				return nil, fmt.Errorf("jsonpath: %q: %w", query, err)
+			}
+			ops = append(ops, op)
+
+		default:
+			return nil, fmt.Errorf("jsonpath: %q: unexpected character %q", query, rest[0])
+		}
+	}
+	return ops, nil
+}
+
+// readName reads the leading name token of s, stopping at '.', '[', or the
+// end of the string. It returns the name and how many bytes were consumed.
+func readName(s string) (string, int) {
+	i := 0
+	for i < len(s) && s[i] != '.' && s[i] != '[' {
+		i++
+	}
+	return s[:i], i
+}
+
+func parseBracket(inner string) (operator, error) {
+	switch {
+	case inner == "*":
+		return operator{kind: opNameWild}, nil
+
+	case strings.Contains(inner, ":"):
+		parts := strings.SplitN(inner, ":", 2)
+		start, err := atoiDefault(parts[0], 0)
+		if err != nil {
+			return operator{}, fmt.Errorf("bad slice start %q: %w", parts[0], err)
+		}
+		if parts[1] == "" {
+			return operator{kind: opIndexRange, start: start, openEnded: true}, nil
+		}
+		end, err := strconv.Atoi(parts[1])
+		if err != nil {
+			return operator{}, fmt.Errorf("bad slice end %q: %w", parts[1], err)
+		}
+		return operator{kind: opIndexRange, start: start, end: end, hasEnd: true}, nil
+
+	case strings.HasPrefix(inner, `"`) && strings.HasSuffix(inner, `"`) && len(inner) >= 2:
+		return operator{kind: opName, name: inner[1 : len(inner)-1]}, nil
+
+	default:
+		idx, err := strconv.Atoi(inner)
+		if err != nil {
+			return operator{}, fmt.Errorf("bad index %q: %w", inner, err)
+		}
+		return operator{kind: opIndexRange, start: idx}, nil
+	}
+}
+
+func atoiDefault(s string, def int) (int, error) {
+	if s == "" {
+		return def, nil
+	}
+	return strconv.Atoi(s)
+}